@@ -0,0 +1,163 @@
+package dotnetdiag
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Client is a connection to the .NET runtime's diagnostics IPC server for a
+// single process. The transport is platform-specific: a Unix domain socket
+// on Linux/macOS, a named pipe on Windows.
+type Client struct {
+	conn io.ReadWriteCloser
+}
+
+// NewClient dials the diagnostic server exposed by the .NET runtime running
+// as pid.
+func NewClient(pid int) (*Client, error) {
+	conn, err := dialTransport(pid)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the underlying transport.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Reader returns the underlying transport. After CollectTracing/
+// CollectTracing2 returns a session ID, the NetTrace stream for that
+// session is read from here until the connection is closed or StopTracing
+// ends the session.
+func (c *Client) Reader() io.Reader {
+	return c.conn
+}
+
+// CollectTracing starts an EventPipe session described by p and returns its
+// session ID. Events can then be read from the same connection.
+func (c *Client) CollectTracing(p CollectTracingPayload) (*CollectTracingResponse, error) {
+	return c.collectTracing(EventPipeCollectTracing, p)
+}
+
+// CollectTracing2 is like CollectTracing but uses the v2 command, which
+// additionally accepts a per-provider FilterData.
+func (c *Client) CollectTracing2(p CollectTracingPayload) (*CollectTracingResponse, error) {
+	return c.collectTracing(EventPipeCollectTracing2, p)
+}
+
+func (c *Client) collectTracing(commandID uint8, p CollectTracingPayload) (*CollectTracingResponse, error) {
+	if err := writeMessage(c.conn, CommandSetEventPipe, commandID, p.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := readResponseHeader(c.conn, CommandSetEventPipe, commandID); err != nil {
+		return nil, err
+	}
+	var resp CollectTracingResponse
+	if err := binary.Read(c.conn, binary.LittleEndian, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StopTracing ends the EventPipe session identified by sessionID.
+func (c *Client) StopTracing(sessionID uint64) (*StopTracingResponse, error) {
+	p := StopTracingPayload{SessionID: sessionID}
+	if err := writeMessage(c.conn, CommandSetEventPipe, EventPipeStopTracing, p.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := readResponseHeader(c.conn, CommandSetEventPipe, EventPipeStopTracing); err != nil {
+		return nil, err
+	}
+	var resp StopTracingResponse
+	if err := binary.Read(c.conn, binary.LittleEndian, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateCoreDump asks the runtime to write a core dump of itself to path.
+func (c *Client) GenerateCoreDump(path string, dumpType DumpType, flags uint32) error {
+	p := GenerateCoreDumpPayload{DumpName: path, DumpType: dumpType, Flags: flags}
+	if err := writeMessage(c.conn, CommandSetDump, DumpGenerateCoreDump, p.Bytes()); err != nil {
+		return err
+	}
+	if err := readResponseHeader(c.conn, CommandSetDump, DumpGenerateCoreDump); err != nil {
+		return err
+	}
+	var hr uint32
+	if err := binary.Read(c.conn, binary.LittleEndian, &hr); err != nil {
+		return err
+	}
+	if hr != 0 {
+		return newDiagnosticError(hr, CommandSetDump, DumpGenerateCoreDump)
+	}
+	return nil
+}
+
+// AttachProfiler loads the ICorProfilerCallback implementation identified by
+// clsid from path into the already-running runtime, passing it clientData
+// via ICorProfilerInfo::GetEnvironmentVariable-style attach data.
+func (c *Client) AttachProfiler(timeoutMs uint32, clsid [16]byte, path string, clientData []byte) error {
+	p := AttachProfilerPayload{AttachTimeoutMs: timeoutMs, ProfilerGUID: clsid, ProfilerPath: path, ClientData: clientData}
+	return c.profilerCommand(ProfilerAttachProfiler, p.Bytes())
+}
+
+// SetStartupProfiler configures the profiler to load on the next managed
+// startup, as if COR_PROFILER/COR_PROFILER_PATH had been set before launch.
+func (c *Client) SetStartupProfiler(clsid [16]byte, path string) error {
+	p := SetStartupProfilerPayload{ProfilerGUID: clsid, ProfilerPath: path}
+	return c.profilerCommand(ProfilerSetStartupProfiler, p.Bytes())
+}
+
+func (c *Client) profilerCommand(commandID uint8, payload []byte) error {
+	if err := writeMessage(c.conn, CommandSetProfiler, commandID, payload); err != nil {
+		return err
+	}
+	if err := readResponseHeader(c.conn, CommandSetProfiler, commandID); err != nil {
+		return err
+	}
+	var hr uint32
+	if err := binary.Read(c.conn, binary.LittleEndian, &hr); err != nil {
+		return err
+	}
+	if hr != 0 {
+		return newDiagnosticError(hr, CommandSetProfiler, commandID)
+	}
+	return nil
+}
+
+// ProcessInfo2 queries the runtime for process identification details.
+func (c *Client) ProcessInfo2() (*ProcessInfo2Response, error) {
+	if err := writeMessage(c.conn, CommandSetProcess, ProcessInfo2, nil); err != nil {
+		return nil, err
+	}
+	if err := readResponseHeader(c.conn, CommandSetProcess, ProcessInfo2); err != nil {
+		return nil, err
+	}
+	var resp ProcessInfo2Response
+	if err := binary.Read(c.conn, binary.LittleEndian, &resp.ProcessId); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(c.conn, binary.LittleEndian, &resp.RuntimeCookie); err != nil {
+		return nil, err
+	}
+	var err error
+	if resp.CommandLine, err = readUtf16String(c.conn); err != nil {
+		return nil, err
+	}
+	if resp.OS, err = readUtf16String(c.conn); err != nil {
+		return nil, err
+	}
+	if resp.Arch, err = readUtf16String(c.conn); err != nil {
+		return nil, err
+	}
+	if resp.ManagedEntrypointAssemblyName, err = readUtf16String(c.conn); err != nil {
+		return nil, err
+	}
+	if resp.ClrProductVersion, err = readUtf16String(c.conn); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
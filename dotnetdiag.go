@@ -18,6 +18,56 @@ var (
 	ErrDiagnosticServer  = fmt.Errorf("diagnostic server")
 )
 
+// Sentinels for the documented HRESULTs the diagnostic server returns in its
+// generic error envelope (CommandSet == CommandSetServer, CommandID ==
+// 0xFF). Match against these with errors.Is; ErrDiagnosticServer itself
+// still matches any of them.
+var (
+	ErrBadEncoding          = fmt.Errorf("%w: bad encoding", ErrDiagnosticServer)
+	ErrUnknownCommand       = fmt.Errorf("%w: unknown command", ErrDiagnosticServer)
+	ErrUnknownMagic         = fmt.Errorf("%w: unknown magic", ErrDiagnosticServer)
+	ErrRuntimeUninitialized = fmt.Errorf("%w: runtime uninitialized", ErrDiagnosticServer)
+	ErrNotSupported         = fmt.Errorf("%w: not supported", ErrDiagnosticServer)
+	ErrBadInput             = fmt.Errorf("%w: bad input", ErrDiagnosticServer)
+)
+
+// diagnosticErrorCodes maps the HRESULTs above to their documented values.
+var diagnosticErrorCodes = map[uint32]error{
+	0x80131384: ErrBadEncoding,
+	0x80131385: ErrUnknownCommand,
+	0x80131386: ErrUnknownMagic,
+	0x80131387: ErrRuntimeUninitialized,
+	0x80131388: ErrNotSupported,
+	0x80131389: ErrBadInput,
+}
+
+// DiagnosticError is returned for any diagnostic-server error envelope. Code
+// is the raw HRESULT; CommandSet/CommandID identify the request that
+// triggered it. errors.Is matches both the specific sentinel (e.g.
+// ErrBadInput) and ErrDiagnosticServer.
+type DiagnosticError struct {
+	Err        error
+	Code       uint32
+	CommandSet uint8
+	CommandID  uint8
+}
+
+func newDiagnosticError(code uint32, commandSet, commandID uint8) *DiagnosticError {
+	err, ok := diagnosticErrorCodes[code]
+	if !ok {
+		err = ErrDiagnosticServer
+	}
+	return &DiagnosticError{Err: err, Code: code, CommandSet: commandSet, CommandID: commandID}
+}
+
+func (e *DiagnosticError) Error() string {
+	return fmt.Sprintf("%s (command set %#x, command %#x, code %#x)", e.Err, e.CommandSet, e.CommandID, e.Code)
+}
+
+func (e *DiagnosticError) Unwrap() error {
+	return e.Err
+}
+
 // DOTNET_IPC_V1 magic header.
 var magic = [...]byte{0x44, 0x4F, 0x54, 0x4E, 0x45, 0x54, 0x5f, 0x49, 0x50, 0x43, 0x5F, 0x56, 0x31, 0x00}
 
@@ -50,6 +100,32 @@ const (
 	ProcessInfo2 = 4
 )
 
+const (
+	_ = iota
+	DumpGenerateCoreDump
+)
+
+const (
+	_ = iota
+	ProfilerAttachProfiler
+	ProfilerSetStartupProfiler
+)
+
+// DumpType selects the amount of process state captured in a core dump.
+type DumpType uint32
+
+const (
+	_ DumpType = iota
+	DumpTypeNormal
+	DumpTypeWithHeap
+	DumpTypeTriage
+	DumpTypeFull
+)
+
+const (
+	DumpFlagLoggingEnabled uint32 = 1 << iota
+)
+
 type CollectTracingPayload struct {
 	CircularBufferSizeMB uint32
 	Format               Format
@@ -86,6 +162,24 @@ type StopTracingResponse struct {
 	SessionID uint64
 }
 
+type GenerateCoreDumpPayload struct {
+	DumpName string
+	DumpType DumpType
+	Flags    uint32
+}
+
+type AttachProfilerPayload struct {
+	AttachTimeoutMs uint32
+	ProfilerGUID    [16]byte
+	ProfilerPath    string
+	ClientData      []byte
+}
+
+type SetStartupProfilerPayload struct {
+	ProfilerGUID [16]byte
+	ProfilerPath string
+}
+
 type ProcessInfo2Response struct {
 	ProcessId                     uint64
 	CommandLine                   string
@@ -114,7 +208,10 @@ func writeMessage(w io.Writer, commandSet, commandID uint8, payload []byte) erro
 	return bw.Flush()
 }
 
-func readResponseHeader(r io.Reader) error {
+// readResponseHeader reads the response Header for a request sent with the
+// given commandSet/commandID, which are only used to annotate a
+// *DiagnosticError should the server report one.
+func readResponseHeader(r io.Reader, commandSet, commandID uint8) error {
 	var h Header
 	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
 		return err
@@ -125,12 +222,11 @@ func readResponseHeader(r io.Reader) error {
 	if !(h.CommandSet == CommandSetServer && h.CommandID == 0xFF) {
 		return nil
 	}
-	// TODO: improve error handling.
 	var er ErrorResponse
 	if err := binary.Read(r, binary.LittleEndian, &er); err != nil {
 		return err
 	}
-	return fmt.Errorf("%w: error code %#x", ErrDiagnosticServer, er.Code)
+	return newDiagnosticError(er.Code, commandSet, commandID)
 }
 
 func (p CollectTracingPayload) Bytes() []byte {
@@ -153,6 +249,31 @@ func (p StopTracingPayload) Bytes() []byte {
 	return b
 }
 
+func (p GenerateCoreDumpPayload) Bytes() []byte {
+	b := new(bytes.Buffer)
+	b.Write(mustStringBytes(p.DumpName))
+	_ = binary.Write(b, binary.LittleEndian, p.DumpType)
+	_ = binary.Write(b, binary.LittleEndian, p.Flags)
+	return b.Bytes()
+}
+
+func (p AttachProfilerPayload) Bytes() []byte {
+	b := new(bytes.Buffer)
+	_ = binary.Write(b, binary.LittleEndian, p.AttachTimeoutMs)
+	b.Write(p.ProfilerGUID[:])
+	b.Write(mustStringBytes(p.ProfilerPath))
+	_ = binary.Write(b, binary.LittleEndian, uint32(len(p.ClientData)))
+	b.Write(p.ClientData)
+	return b.Bytes()
+}
+
+func (p SetStartupProfilerPayload) Bytes() []byte {
+	b := new(bytes.Buffer)
+	b.Write(p.ProfilerGUID[:])
+	b.Write(mustStringBytes(p.ProfilerPath))
+	return b.Bytes()
+}
+
 var enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
 
 func mustStringBytes(s string) []byte {
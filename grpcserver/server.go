@@ -0,0 +1,245 @@
+// Package grpcserver implements the dotnetdiag.v1.DiagnosticService grpc
+// and grpc-gateway REST facade defined in api/proto/v1/dotnetdiag.proto,
+// wrapping a dotnetdiag.Client per request.
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/vizares/dotnetdiag"
+	dotnetdiagv1 "github.com/vizares/dotnetdiag/api/proto/v1"
+	"github.com/vizares/dotnetdiag/nettrace"
+)
+
+// Server implements dotnetdiagv1.DiagnosticServiceServer against a
+// dotnetdiag.Client dialed fresh for every RPC; the diagnostic IPC protocol
+// has no notion of a long-lived session shared across requests.
+type Server struct {
+	dotnetdiagv1.UnimplementedDiagnosticServiceServer
+}
+
+// NewServer returns a Server ready to be registered on a grpc.Server via
+// dotnetdiagv1.RegisterDiagnosticServiceServer.
+func NewServer() *Server {
+	return &Server{}
+}
+
+func dial(pid int32) (*dotnetdiag.Client, error) {
+	c, err := dotnetdiag.NewClient(int(pid))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "dial pid %d: %v", pid, err)
+	}
+	return c, nil
+}
+
+// statusFromError maps a *dotnetdiag.DiagnosticError to the gRPC status
+// code that best matches its sentinel; any other error becomes codes.Internal.
+func statusFromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var de *dotnetdiag.DiagnosticError
+	if !errors.As(err, &de) {
+		return status.Error(codes.Internal, err.Error())
+	}
+	switch {
+	case errors.Is(de, dotnetdiag.ErrBadInput):
+		return status.Error(codes.InvalidArgument, de.Error())
+	case errors.Is(de, dotnetdiag.ErrNotSupported):
+		return status.Error(codes.Unimplemented, de.Error())
+	case errors.Is(de, dotnetdiag.ErrRuntimeUninitialized):
+		return status.Error(codes.FailedPrecondition, de.Error())
+	default:
+		return status.Error(codes.Internal, de.Error())
+	}
+}
+
+func (s *Server) CollectTracing(req *dotnetdiagv1.CollectTracingRequest, stream dotnetdiagv1.DiagnosticService_CollectTracingServer) error {
+	c, err := dial(req.Pid)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	payload := dotnetdiag.CollectTracingPayload{
+		CircularBufferSizeMB: req.CircularBufferSizeMb,
+		Format:               dotnetdiag.Format(req.Format),
+		Providers:            toProviderConfigs(req.Providers),
+	}
+	if req.UseFilterData {
+		if _, err := c.CollectTracing2(payload); err != nil {
+			return statusFromError(err)
+		}
+	} else {
+		if _, err := c.CollectTracing(payload); err != nil {
+			return statusFromError(err)
+		}
+	}
+
+	if !req.Decode {
+		return streamRaw(c.Reader(), stream)
+	}
+	return streamDecoded(c.Reader(), stream)
+}
+
+func streamRaw(r io.Reader, stream dotnetdiagv1.DiagnosticService_CollectTracingServer) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := &dotnetdiagv1.TraceChunk{Payload: &dotnetdiagv1.TraceChunk_Raw{Raw: append([]byte(nil), buf[:n]...)}}
+			if sendErr := stream.Send(chunk); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+func streamDecoded(r io.Reader, stream dotnetdiagv1.DiagnosticService_CollectTracingServer) error {
+	er, err := nettrace.NewEventReader(r)
+	if err != nil {
+		return status.Errorf(codes.Internal, "nettrace: %v", err)
+	}
+	for {
+		ev, err := er.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "nettrace: %v", err)
+		}
+		payloadJSON, err := json.Marshal(ev.Payload)
+		if err != nil {
+			return status.Errorf(codes.Internal, "marshal event payload: %v", err)
+		}
+		chunk := &dotnetdiagv1.TraceChunk{Payload: &dotnetdiagv1.TraceChunk_Event{Event: &dotnetdiagv1.Event{
+			ProviderName:      ev.ProviderName,
+			Id:                ev.ID,
+			Keywords:          ev.Keywords,
+			Level:             ev.Level,
+			TimestampUnixNano: ev.Timestamp.UnixNano(),
+			ThreadId:          ev.ThreadID,
+			PayloadJson:       payloadJSON,
+		}}}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) StopTracing(ctx context.Context, req *dotnetdiagv1.StopTracingRequest) (*dotnetdiagv1.StopTracingResponse, error) {
+	c, err := dial(req.Pid)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	resp, err := c.StopTracing(req.SessionId)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &dotnetdiagv1.StopTracingResponse{SessionId: resp.SessionID}, nil
+}
+
+func (s *Server) ProcessInfo(ctx context.Context, req *dotnetdiagv1.ProcessInfoRequest) (*dotnetdiagv1.ProcessInfoResponse, error) {
+	c, err := dial(req.Pid)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	resp, err := c.ProcessInfo2()
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return &dotnetdiagv1.ProcessInfoResponse{
+		ProcessId:                     resp.ProcessId,
+		CommandLine:                   resp.CommandLine,
+		Os:                            resp.OS,
+		Arch:                          resp.Arch,
+		RuntimeCookie:                 resp.RuntimeCookie[:],
+		ManagedEntrypointAssemblyName: resp.ManagedEntrypointAssemblyName,
+		ClrProductVersion:             resp.ClrProductVersion,
+	}, nil
+}
+
+func (s *Server) GenerateCoreDump(ctx context.Context, req *dotnetdiagv1.GenerateCoreDumpRequest) (*dotnetdiagv1.GenerateCoreDumpResponse, error) {
+	c, err := dial(req.Pid)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if err := c.GenerateCoreDump(req.Path, dotnetdiag.DumpType(req.DumpType), req.Flags); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &dotnetdiagv1.GenerateCoreDumpResponse{}, nil
+}
+
+func (s *Server) AttachProfiler(ctx context.Context, req *dotnetdiagv1.AttachProfilerRequest) (*dotnetdiagv1.AttachProfilerResponse, error) {
+	c, err := dial(req.Pid)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	clsid, err := clsidFromBytes(req.Clsid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := c.AttachProfiler(req.AttachTimeoutMs, clsid, req.ProfilerPath, req.ClientData); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &dotnetdiagv1.AttachProfilerResponse{}, nil
+}
+
+func (s *Server) SetStartupProfiler(ctx context.Context, req *dotnetdiagv1.SetStartupProfilerRequest) (*dotnetdiagv1.SetStartupProfilerResponse, error) {
+	c, err := dial(req.Pid)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	clsid, err := clsidFromBytes(req.Clsid)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := c.SetStartupProfiler(clsid, req.ProfilerPath); err != nil {
+		return nil, statusFromError(err)
+	}
+	return &dotnetdiagv1.SetStartupProfilerResponse{}, nil
+}
+
+func clsidFromBytes(b []byte) ([16]byte, error) {
+	var clsid [16]byte
+	if len(b) != len(clsid) {
+		return clsid, errors.New("clsid must be exactly 16 bytes")
+	}
+	copy(clsid[:], b)
+	return clsid, nil
+}
+
+func toProviderConfigs(in []*dotnetdiagv1.ProviderConfig) []dotnetdiag.ProviderConfig {
+	out := make([]dotnetdiag.ProviderConfig, len(in))
+	for i, p := range in {
+		out[i] = dotnetdiag.ProviderConfig{
+			Keywords:     p.Keywords,
+			LogLevel:     p.LogLevel,
+			ProviderName: p.ProviderName,
+			FilterData:   p.FilterData,
+		}
+	}
+	return out
+}
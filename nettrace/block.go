@@ -0,0 +1,247 @@
+package nettrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// blockHeader is the fixed-size header at the front of every EventBlock and
+// MetadataBlock, immediately following the block's own BeginPrivateObject
+// tag. HeaderSize tells us how much of it to skip before the event blobs
+// start, which is what lets the same code handle both the V4 (20 byte) and
+// V5 (28 byte, adds Min/MaxTimestamp) header layouts.
+type blockHeader struct {
+	HeaderSize   uint16
+	Flags        uint16
+	MinTimestamp int64
+	MaxTimestamp int64
+}
+
+const (
+	blockHeaderFlagCompressed uint16 = 1 << iota
+)
+
+func readBlockHeader(r *bytes.Reader) (blockHeader, error) {
+	var h blockHeader
+	if err := binary.Read(r, binary.LittleEndian, &h.HeaderSize); err != nil {
+		return h, err
+	}
+	if h.HeaderSize < 2 {
+		return h, fmt.Errorf("nettrace: implausible block header size %d", h.HeaderSize)
+	}
+	rest := make([]byte, h.HeaderSize-2)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return h, err
+	}
+	rr := bytes.NewReader(rest)
+	if rr.Len() >= 2 {
+		_ = binary.Read(rr, binary.LittleEndian, &h.Flags)
+	}
+	if rr.Len() >= 16 {
+		_ = binary.Read(rr, binary.LittleEndian, &h.MinTimestamp)
+		_ = binary.Read(rr, binary.LittleEndian, &h.MaxTimestamp)
+	}
+	return h, nil
+}
+
+// decodedEvent is one event blob out of an EventBlock or MetadataBlock,
+// before its payload is resolved against metadata.
+type decodedEvent struct {
+	metadataID      uint32
+	sequenceNumber  uint32
+	threadID        uint64
+	captureThreadID uint64
+	processorNumber uint32
+	stackID         uint32
+	timestamp       int64
+	activityID      [16]byte
+	relatedActivity [16]byte
+	payload         []byte
+}
+
+// decodeEventSequence decodes every event blob in a block body. Bodies use
+// either the V4 fixed-width layout or the V5 layout, in which each blob
+// starts with a bit flag selecting which fields changed since the previous
+// blob with the same MetadataId (encoded as LEB128 varints) to avoid
+// repeating mostly-constant fields such as ThreadId.
+func decodeEventSequence(body []byte, header blockHeader) ([]decodedEvent, error) {
+	r := bytes.NewReader(body)
+	if header.Flags&blockHeaderFlagCompressed == 0 {
+		return decodeEventsV4(r)
+	}
+	return decodeEventsV5(r)
+}
+
+// eventBlobHeaderV4 mirrors the uncompressed EventBlobHeader: an EventSize
+// (the size of everything that follows, unused here since we rely on
+// r.Len()), MetadataId and SequenceNumber/ProcessorNumber/StackId as int32,
+// and ThreadId/CaptureThreadId as int64.
+func decodeEventsV4(r *bytes.Reader) ([]decodedEvent, error) {
+	var events []decodedEvent
+	for r.Len() > 0 {
+		var e decodedEvent
+		var eventSize int32
+		if err := binary.Read(r, binary.LittleEndian, &eventSize); err != nil {
+			return nil, err
+		}
+		var metadataID, sequenceNumber, processorNumber, stackID int32
+		var threadID, captureThreadID int64
+		if err := binary.Read(r, binary.LittleEndian, &metadataID); err != nil {
+			return nil, err
+		}
+		e.metadataID = uint32(metadataID)
+		if err := binary.Read(r, binary.LittleEndian, &sequenceNumber); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &threadID); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &captureThreadID); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &processorNumber); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &stackID); err != nil {
+			return nil, err
+		}
+		e.sequenceNumber = uint32(sequenceNumber)
+		e.threadID = uint64(threadID)
+		e.captureThreadID = uint64(captureThreadID)
+		e.processorNumber = uint32(processorNumber)
+		e.stackID = uint32(stackID)
+		if err := binary.Read(r, binary.LittleEndian, &e.timestamp); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, e.activityID[:]); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, e.relatedActivity[:]); err != nil {
+			return nil, err
+		}
+		var payloadSize int32
+		if err := binary.Read(r, binary.LittleEndian, &payloadSize); err != nil {
+			return nil, err
+		}
+		payload := make([]byte, payloadSize)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		e.payload = payload
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// v5 compressed blob flag bits, one per field that may be omitted when
+// unchanged from the previous blob sharing the same MetadataId.
+const (
+	v5FlagMetadataID uint8 = 1 << iota
+	v5FlagCaptureThreadIDAndSequenceNumber
+	v5FlagThreadID
+	v5FlagStackID
+	v5FlagActivityID
+	v5FlagRelatedActivityID
+	v5FlagPayloadSize
+	v5FlagIsSorted
+)
+
+func decodeEventsV5(r *bytes.Reader) ([]decodedEvent, error) {
+	var events []decodedEvent
+	last := map[uint32]decodedEvent{}
+	var prevMetadataID uint32
+	for r.Len() > 0 {
+		flags, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		prev := last[prevMetadataID]
+		e := prev
+
+		if flags&v5FlagMetadataID != 0 {
+			id, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			e.metadataID = uint32(id)
+		}
+		if flags&v5FlagCaptureThreadIDAndSequenceNumber != 0 {
+			seqDelta, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			e.sequenceNumber = prev.sequenceNumber + uint32(seqDelta) + 1
+			tid, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			e.captureThreadID = tid
+			procNumber, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			e.processorNumber = uint32(procNumber)
+		} else {
+			e.sequenceNumber = prev.sequenceNumber
+			if e.metadataID != 0 {
+				e.sequenceNumber++
+			}
+		}
+		if flags&v5FlagThreadID != 0 {
+			tid, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			e.threadID = tid
+		}
+		if flags&v5FlagStackID != 0 {
+			sid, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			e.stackID = uint32(sid)
+		}
+		tsDelta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		e.timestamp = prev.timestamp + int64(tsDelta)
+
+		if flags&v5FlagActivityID != 0 {
+			if _, err := io.ReadFull(r, e.activityID[:]); err != nil {
+				return nil, err
+			}
+		}
+		if flags&v5FlagRelatedActivityID != 0 {
+			if _, err := io.ReadFull(r, e.relatedActivity[:]); err != nil {
+				return nil, err
+			}
+		}
+
+		var payloadSize uint64
+		if flags&v5FlagPayloadSize != 0 {
+			payloadSize, err = binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			payloadSize = uint64(len(prev.payload))
+		}
+		payload := make([]byte, payloadSize)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		e.payload = payload
+
+		prevMetadataID = e.metadataID
+		last[e.metadataID] = e
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func newByteReader(b []byte) *bytes.Reader {
+	return bytes.NewReader(b)
+}
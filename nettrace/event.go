@@ -0,0 +1,58 @@
+package nettrace
+
+import "time"
+
+// Event is a single EventPipe event decoded from an EventBlock, with its
+// payload fields resolved against the MetadataBlock that described them.
+type Event struct {
+	ProviderName string
+	ID           uint32
+	Keywords     uint64
+	Level        uint32
+	Timestamp    time.Time
+	ThreadID     uint64
+	Payload      map[string]interface{}
+}
+
+// eventMetadata is the decoded contents of one entry in a MetadataBlock,
+// keyed by MetadataId in the reader's metadata table.
+type eventMetadata struct {
+	ProviderName string
+	EventID      uint32
+	EventName    string
+	Keywords     uint64
+	Version      uint32
+	Level        uint32
+	Fields       []fieldDescriptor
+}
+
+// typeCode mirrors the subset of System.TypeCode (plus the EventPipe-only
+// Array extension) that CoreCLR actually emits in metadata field
+// descriptors.
+type typeCode int32
+
+const (
+	typeCodeBoolean  typeCode = 3
+	typeCodeChar     typeCode = 4
+	typeCodeSByte    typeCode = 5
+	typeCodeByte     typeCode = 6
+	typeCodeInt16    typeCode = 7
+	typeCodeUInt16   typeCode = 8
+	typeCodeInt32    typeCode = 9
+	typeCodeUInt32   typeCode = 10
+	typeCodeInt64    typeCode = 11
+	typeCodeUInt64   typeCode = 12
+	typeCodeSingle   typeCode = 13
+	typeCodeDouble   typeCode = 14
+	typeCodeDateTime typeCode = 16
+	typeCodeGuid     typeCode = 17
+	typeCodeString   typeCode = 18
+	typeCodeObject   typeCode = 1  // nested struct: Fields describes its members
+	typeCodeArray    typeCode = 19 // EventPipe-specific: ElemType/Fields describes the element
+)
+
+type fieldDescriptor struct {
+	Name   string
+	Type   typeCode
+	Fields []fieldDescriptor // members, when Type == typeCodeObject or the element of an Array
+}
@@ -0,0 +1,125 @@
+package nettrace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// decodeMetadataBlock parses a MetadataBlock and registers every metadata
+// event it contains in table, keyed by MetadataId.
+func decodeMetadataBlock(body []byte, header blockHeader, table map[uint32]*eventMetadata) error {
+	events, err := decodeEventSequence(body, header)
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		metadataID, md, err := decodeEventMetadata(ev.payload)
+		if err != nil {
+			return fmt.Errorf("nettrace: decode metadata event: %w", err)
+		}
+		table[metadataID] = md
+	}
+	return nil
+}
+
+// decodeEventMetadata parses the payload of a single metadata event,
+// returning the MetadataId it describes (not to be confused with the
+// containing blob's own MetadataId, which is always 0 for metadata
+// events) along with the provider/event identity and field descriptors
+// used to decode payloads of matching events.
+func decodeEventMetadata(payload []byte) (uint32, *eventMetadata, error) {
+	r := newByteReader(payload)
+
+	var metadataID uint32
+	if err := binary.Read(r, binary.LittleEndian, &metadataID); err != nil {
+		return 0, nil, err
+	}
+	providerName, err := readNulTerminatedUTF16(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var eventID uint32
+	if err := binary.Read(r, binary.LittleEndian, &eventID); err != nil {
+		return 0, nil, err
+	}
+	eventName, err := readNulTerminatedUTF16(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var keywords uint64
+	var eventVersion uint32
+	var level uint32
+	if err := binary.Read(r, binary.LittleEndian, &keywords); err != nil {
+		return 0, nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &eventVersion); err != nil {
+		return 0, nil, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &level); err != nil {
+		return 0, nil, err
+	}
+	var fieldCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &fieldCount); err != nil {
+		return 0, nil, err
+	}
+	fields := make([]fieldDescriptor, 0, fieldCount)
+	for i := uint32(0); i < fieldCount; i++ {
+		f, err := decodeFieldDescriptor(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		fields = append(fields, f)
+	}
+	return metadataID, &eventMetadata{
+		ProviderName: providerName,
+		EventID:      eventID,
+		EventName:    eventName,
+		Version:      eventVersion,
+		Keywords:     keywords,
+		Level:        level,
+		Fields:       fields,
+	}, nil
+}
+
+func decodeFieldDescriptor(r io.Reader) (fieldDescriptor, error) {
+	var typeCodeRaw int32
+	if err := binary.Read(r, binary.LittleEndian, &typeCodeRaw); err != nil {
+		return fieldDescriptor{}, err
+	}
+	name, err := readNulTerminatedUTF16(r)
+	if err != nil {
+		return fieldDescriptor{}, err
+	}
+	f := fieldDescriptor{Name: name, Type: typeCode(typeCodeRaw)}
+	if f.Type == typeCodeObject || f.Type == typeCodeArray {
+		var memberCount uint32
+		if err := binary.Read(r, binary.LittleEndian, &memberCount); err != nil {
+			return fieldDescriptor{}, err
+		}
+		for i := uint32(0); i < memberCount; i++ {
+			member, err := decodeFieldDescriptor(r)
+			if err != nil {
+				return fieldDescriptor{}, err
+			}
+			f.Fields = append(f.Fields, member)
+		}
+	}
+	return f, nil
+}
+
+func readNulTerminatedUTF16(r io.Reader) (string, error) {
+	var units []uint16
+	for {
+		var u uint16
+		if err := binary.Read(r, binary.LittleEndian, &u); err != nil {
+			return "", err
+		}
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units)), nil
+}
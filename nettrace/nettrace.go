@@ -0,0 +1,119 @@
+// Package nettrace decodes the NetTrace/NetPerf stream produced by the .NET
+// runtime's EventPipe (the payload returned by Client.CollectTracing). The
+// stream is a FastSerialization object graph: a small header followed by a
+// sequence of tagged objects, the ones of interest here being EventBlock,
+// MetadataBlock, StackBlock and SequencePointBlock.
+package nettrace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// fileMagic is the 8-byte identifier at the start of every NetTrace stream,
+// regardless of the Format (NetPerf/NetTrace) requested at collection time.
+var fileMagic = [8]byte{'N', 'e', 't', 't', 'r', 'a', 'c', 'e'}
+
+// streamHeader is the FastSerialization preamble, a length-prefixed ASCII
+// string identifying the serializer version.
+const streamHeader = "!FastSerialization.1"
+
+// Tag identifies the kind of object that follows in the FastSerialization
+// stream.
+type tag byte
+
+const (
+	tagError              tag = 0
+	tagNullReference      tag = 1
+	tagBeginPrivateObject tag = 5
+	tagEndObject          tag = 6
+)
+
+// blockName identifies the payload of a BeginPrivateObject by its declared
+// .NET type name.
+const (
+	blockNameTrace         = "Trace"
+	blockNameEventBlock    = "EventBlock"
+	blockNameMetadataBlock = "MetadataBlock"
+	blockNameStackBlock    = "StackBlock"
+	blockNameSPBlock       = "SPBlock" // SequencePointBlock
+	blockNameEventTrace    = "EventTrace"
+)
+
+func readTag(r io.Reader) (tag, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return tag(b[0]), nil
+}
+
+// readObjectHeader consumes a BeginPrivateObject tag plus the type
+// descriptor that follows it (type name, version, minimum reader version)
+// and returns the declared type name. Nested/forward-referenced type
+// descriptors (tag 2, "UseObjectReference") are not emitted by EventPipe and
+// are not handled here.
+func readObjectHeader(r io.Reader) (string, error) {
+	t, err := readTag(r)
+	if err != nil {
+		return "", err
+	}
+	if t != tagBeginPrivateObject {
+		return "", fmt.Errorf("nettrace: expected BeginPrivateObject tag, got %d", t)
+	}
+	// Nested "BeginPrivateObject" for the type descriptor itself.
+	if t, err = readTag(r); err != nil {
+		return "", err
+	}
+	if t != tagBeginPrivateObject {
+		return "", fmt.Errorf("nettrace: expected type descriptor tag, got %d", t)
+	}
+	// The type descriptor's own type is a NullReference, terminating the
+	// recursion; it has no payload of its own.
+	if t, err = readTag(r); err != nil {
+		return "", err
+	}
+	if t != tagNullReference {
+		return "", fmt.Errorf("nettrace: expected NullReference tag, got %d", t)
+	}
+	var version, minReaderVersion int32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return "", err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &minReaderVersion); err != nil {
+		return "", err
+	}
+	name, err := readLengthPrefixedString(r)
+	if err != nil {
+		return "", err
+	}
+	// Closes the type descriptor object.
+	if _, err := readTag(r); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func readLengthPrefixedString(r io.Reader) (string, error) {
+	var n int32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if n < 0 {
+		return "", fmt.Errorf("nettrace: negative string length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func newBufioReader(r io.Reader) *bufio.Reader {
+	if br, ok := r.(*bufio.Reader); ok {
+		return br
+	}
+	return bufio.NewReaderSize(r, 64*1024)
+}
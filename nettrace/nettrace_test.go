@@ -0,0 +1,208 @@
+package nettrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// buildObject encodes a FastSerialization object: BeginPrivateObject, its
+// SerializedType (itself an object whose type is NullReference), then body,
+// then the closing EndObject for the outer object. This mirrors exactly
+// what readObjectHeader/readObjectHeaderBody expect to consume.
+func buildObject(typeName string, body []byte) []byte {
+	buf := new(bytes.Buffer)
+	appendObjectHeader(buf, typeName)
+	buf.Write(body)
+	buf.WriteByte(byte(tagEndObject)) // closes the outer object
+	return buf.Bytes()
+}
+
+// appendObjectHeader writes the BeginPrivateObject tag and SerializedType
+// descriptor for typeName directly into buf.
+func appendObjectHeader(buf *bytes.Buffer, typeName string) {
+	buf.WriteByte(byte(tagBeginPrivateObject))
+	buf.WriteByte(byte(tagBeginPrivateObject))
+	buf.WriteByte(byte(tagNullReference))
+	_ = binary.Write(buf, binary.LittleEndian, int32(1)) // version
+	_ = binary.Write(buf, binary.LittleEndian, int32(0)) // minReaderVersion
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(typeName)))
+	buf.WriteString(typeName)
+	buf.WriteByte(byte(tagEndObject)) // closes SerializedType
+}
+
+func nulTerminatedUTF16Bytes(s string) []byte {
+	buf := new(bytes.Buffer)
+	for _, u := range utf16.Encode([]rune(s)) {
+		_ = binary.Write(buf, binary.LittleEndian, u)
+	}
+	_ = binary.Write(buf, binary.LittleEndian, uint16(0))
+	return buf.Bytes()
+}
+
+// buildEventBlobV4 encodes one uncompressed EventBlobHeader + payload.
+func buildEventBlobV4(metadataID uint32, threadID uint64, timestamp int64, payload []byte) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, int32(0))            // EventSize, unused by the decoder
+	_ = binary.Write(buf, binary.LittleEndian, int32(metadataID))   // MetadataId
+	_ = binary.Write(buf, binary.LittleEndian, int32(0))            // SequenceNumber
+	_ = binary.Write(buf, binary.LittleEndian, int64(threadID))     // ThreadId
+	_ = binary.Write(buf, binary.LittleEndian, int64(threadID))     // CaptureThreadId
+	_ = binary.Write(buf, binary.LittleEndian, int32(0))            // ProcessorNumber
+	_ = binary.Write(buf, binary.LittleEndian, int32(0))            // StackId
+	_ = binary.Write(buf, binary.LittleEndian, timestamp)           // TimeStamp
+	buf.Write(make([]byte, 16))                                     // ActivityId
+	buf.Write(make([]byte, 16))                                     // RelatedActivityId
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(payload))) // PayloadSize
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// appendBlock writes one uncompressed (V4) Block object (MetadataBlock or
+// EventBlock) directly into buf: a minimal 2-byte header (HeaderSize=2, no
+// flags) followed by the event blobs, all prefixed with the block's int32
+// byte-size as readNextBlock expects, with the padding NetTrace inserts
+// after that size to keep block data 4-byte aligned relative to the start
+// of the stream.
+func appendBlock(buf *bytes.Buffer, typeName string, blobs ...[]byte) {
+	appendObjectHeader(buf, typeName)
+
+	content := new(bytes.Buffer)
+	_ = binary.Write(content, binary.LittleEndian, uint16(2)) // HeaderSize, no flags
+	for _, b := range blobs {
+		content.Write(b)
+	}
+	_ = binary.Write(buf, binary.LittleEndian, int32(content.Len()))
+	if pad := -buf.Len() & 3; pad != 0 {
+		buf.Write(make([]byte, pad))
+	}
+	buf.Write(content.Bytes())
+	buf.WriteByte(byte(tagEndObject)) // closes the outer object
+}
+
+func buildMetadataPayload(metadataID uint32, providerName string, eventID uint32, eventName string, keywords uint64, version, level uint32, fieldName string, fieldType typeCode) []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, metadataID)
+	buf.Write(nulTerminatedUTF16Bytes(providerName))
+	_ = binary.Write(buf, binary.LittleEndian, eventID)
+	buf.Write(nulTerminatedUTF16Bytes(eventName))
+	_ = binary.Write(buf, binary.LittleEndian, keywords)
+	_ = binary.Write(buf, binary.LittleEndian, version)
+	_ = binary.Write(buf, binary.LittleEndian, level)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(1)) // field count
+	_ = binary.Write(buf, binary.LittleEndian, int32(fieldType))
+	buf.Write(nulTerminatedUTF16Bytes(fieldName))
+	return buf.Bytes()
+}
+
+const (
+	testSyncQPC  = 1000
+	testQPCFreq  = 100
+	testEventQPC = 1100 // one second after testSyncQPC at testQPCFreq
+)
+
+var testSyncTime = time.Date(2024, time.January, 2, 3, 4, 5, 6*int(time.Millisecond), time.UTC)
+
+func buildStream(t *testing.T) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	buf.Write(fileMagic[:])
+	_ = binary.Write(buf, binary.LittleEndian, int32(len(streamHeader)))
+	buf.WriteString(streamHeader)
+
+	traceBody := new(bytes.Buffer)
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Year()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Month()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Weekday()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Day()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Hour()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Minute()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Second()))
+	_ = binary.Write(traceBody, binary.LittleEndian, int16(testSyncTime.Nanosecond()/int(time.Millisecond)))
+	_ = binary.Write(traceBody, binary.LittleEndian, int64(testSyncQPC)) // SyncTimeQPC
+	_ = binary.Write(traceBody, binary.LittleEndian, int64(testQPCFreq)) // QPCFrequency
+	_ = binary.Write(traceBody, binary.LittleEndian, int32(8))           // PointerSize
+	buf.Write(buildObject(blockNameTrace, traceBody.Bytes()))
+
+	metadataPayload := buildMetadataPayload(1, "MyProvider", 42, "MyEvent", 0xF, 2, 4, "Count", typeCodeInt32)
+	metadataBlob := buildEventBlobV4(0, 0, 0, metadataPayload)
+	appendBlock(buf, blockNameMetadataBlock, metadataBlob)
+
+	eventPayload := make([]byte, 4)
+	binary.LittleEndian.PutUint32(eventPayload, 123)
+	eventBlob := buildEventBlobV4(1, 7, testEventQPC, eventPayload)
+	appendBlock(buf, blockNameEventBlock, eventBlob)
+
+	buf.WriteByte(byte(tagNullReference)) // stream terminator
+
+	return buf.Bytes()
+}
+
+func TestDecodeEventMetadata(t *testing.T) {
+	payload := buildMetadataPayload(1, "MyProvider", 42, "MyEvent", 0xF, 2, 4, "Count", typeCodeInt32)
+	metadataID, md, err := decodeEventMetadata(payload)
+	if err != nil {
+		t.Fatalf("decodeEventMetadata: %v", err)
+	}
+	if metadataID != 1 {
+		t.Errorf("metadataID = %d, want 1", metadataID)
+	}
+	if md.ProviderName != "MyProvider" {
+		t.Errorf("ProviderName = %q, want %q", md.ProviderName, "MyProvider")
+	}
+	if md.EventID != 42 {
+		t.Errorf("EventID = %d, want 42", md.EventID)
+	}
+	if md.EventName != "MyEvent" {
+		t.Errorf("EventName = %q, want %q", md.EventName, "MyEvent")
+	}
+	if md.Keywords != 0xF {
+		t.Errorf("Keywords = %#x, want 0xF", md.Keywords)
+	}
+	if md.Version != 2 {
+		t.Errorf("Version = %d, want 2", md.Version)
+	}
+	if md.Level != 4 {
+		t.Errorf("Level = %d, want 4", md.Level)
+	}
+}
+
+func TestEventReaderRoundTrip(t *testing.T) {
+	stream := buildStream(t)
+	er, err := NewEventReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("NewEventReader: %v", err)
+	}
+
+	ev, err := er.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if ev.ProviderName != "MyProvider" {
+		t.Errorf("ProviderName = %q, want %q", ev.ProviderName, "MyProvider")
+	}
+	if ev.ID != 42 {
+		t.Errorf("ID = %d, want 42", ev.ID)
+	}
+	if ev.Level != 4 {
+		t.Errorf("Level = %d, want 4", ev.Level)
+	}
+	if ev.ThreadID != 7 {
+		t.Errorf("ThreadID = %d, want 7", ev.ThreadID)
+	}
+	wantTimestamp := testSyncTime.Add(time.Second)
+	if !ev.Timestamp.Equal(wantTimestamp) {
+		t.Errorf("Timestamp = %v, want %v", ev.Timestamp, wantTimestamp)
+	}
+	count, ok := ev.Payload["Count"].(int32)
+	if !ok || count != 123 {
+		t.Errorf("Payload[Count] = %#v, want int32(123)", ev.Payload["Count"])
+	}
+
+	if _, err := er.Next(); err != io.EOF {
+		t.Errorf("second Next() = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,123 @@
+package nettrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// decodePayload walks payload sequentially according to fields, the
+// descriptors recorded for this event id in its MetadataBlock entry.
+func decodePayload(payload []byte, fields []fieldDescriptor) (map[string]interface{}, error) {
+	r := bytes.NewReader(payload)
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		v, err := decodeField(r, f)
+		if err != nil {
+			return nil, fmt.Errorf("nettrace: field %q: %w", f.Name, err)
+		}
+		out[f.Name] = v
+	}
+	return out, nil
+}
+
+func decodeField(r *bytes.Reader, f fieldDescriptor) (interface{}, error) {
+	switch f.Type {
+	case typeCodeBoolean:
+		var v int32 // bool fields are serialized as a 4-byte Win32 BOOL
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return v != 0, nil
+	case typeCodeChar:
+		var v uint16
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return rune(v), nil
+	case typeCodeSByte:
+		var v int8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeByte:
+		var v uint8
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeInt16:
+		var v int16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeUInt16:
+		var v uint16
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeInt32:
+		var v int32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeUInt32:
+		var v uint32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeInt64:
+		var v int64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeUInt64:
+		var v uint64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeSingle:
+		var v float32
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeDouble:
+		var v float64
+		err := binary.Read(r, binary.LittleEndian, &v)
+		return v, err
+	case typeCodeDateTime:
+		var v int64 // FILETIME
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case typeCodeGuid:
+		var v [16]byte
+		if _, err := r.Read(v[:]); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case typeCodeString:
+		return readNulTerminatedUTF16(r)
+	case typeCodeObject:
+		members := make(map[string]interface{}, len(f.Fields))
+		for _, mf := range f.Fields {
+			v, err := decodeField(r, mf)
+			if err != nil {
+				return nil, fmt.Errorf("member %q: %w", mf.Name, err)
+			}
+			members[mf.Name] = v
+		}
+		return members, nil
+	case typeCodeArray:
+		var count uint16
+		if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+			return nil, err
+		}
+		elem := fieldDescriptor{Name: f.Name, Type: typeCode(0)}
+		if len(f.Fields) == 1 {
+			elem = f.Fields[0]
+		}
+		out := make([]interface{}, count)
+		for i := range out {
+			v, err := decodeField(r, elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported field type code %d", f.Type)
+	}
+}
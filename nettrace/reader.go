@@ -0,0 +1,269 @@
+package nettrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EventReader decodes the stream of Event values out of a NetTrace/NetPerf
+// byte stream, such as the socket handed back after Client.CollectTracing.
+type EventReader struct {
+	r        *countingReader
+	syncTime time.Time // QPC-to-wall-clock anchor from the Trace object
+	syncQPC  int64     // QPC counter value at syncTime
+	qpcFreq  int64
+	metadata map[uint32]*eventMetadata
+	pending  []Event
+	done     bool
+}
+
+// countingReader tracks how many bytes have been read from the stream so
+// readNextBlock can skip the padding NetTrace inserts to keep block data
+// 4-byte aligned relative to the start of the stream.
+type countingReader struct {
+	r   io.Reader
+	pos int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.pos += int64(n)
+	return n, err
+}
+
+// NewEventReader validates the stream header and prepares r for decoding.
+func NewEventReader(r io.Reader) (*EventReader, error) {
+	cr := &countingReader{r: newBufioReader(r)}
+
+	var magic [8]byte
+	if _, err := io.ReadFull(cr, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != fileMagic {
+		return nil, fmt.Errorf("nettrace: missing Nettrace file magic")
+	}
+	header, err := readLengthPrefixedString(cr)
+	if err != nil {
+		return nil, err
+	}
+	if header != streamHeader {
+		return nil, fmt.Errorf("nettrace: unsupported serializer header %q", header)
+	}
+
+	er := &EventReader{r: cr, metadata: make(map[uint32]*eventMetadata)}
+	if err := er.readTraceObject(); err != nil {
+		return nil, err
+	}
+	return er, nil
+}
+
+// readTraceObject consumes the mandatory leading Trace object, which
+// establishes the QPC-to-wall-clock conversion used to timestamp every
+// subsequent event.
+func (er *EventReader) readTraceObject() error {
+	name, err := readObjectHeader(er.r)
+	if err != nil {
+		return err
+	}
+	if name != blockNameTrace {
+		return fmt.Errorf("nettrace: expected Trace object, got %q", name)
+	}
+	var year, month, dayOfWeek, day, hour, minute, second, milli int16
+	for _, v := range []*int16{&year, &month, &dayOfWeek, &day, &hour, &minute, &second, &milli} {
+		if err := binary.Read(er.r, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	var syncQPC int64
+	var qpcFreq int64
+	if err := binary.Read(er.r, binary.LittleEndian, &syncQPC); err != nil {
+		return err
+	}
+	if err := binary.Read(er.r, binary.LittleEndian, &qpcFreq); err != nil {
+		return err
+	}
+	var pointerSize int32
+	if err := binary.Read(er.r, binary.LittleEndian, &pointerSize); err != nil {
+		return err
+	}
+	er.qpcFreq = qpcFreq
+	er.syncQPC = syncQPC
+	er.syncTime = time.Date(int(year), time.Month(month), int(day), int(hour), int(minute), int(second), int(milli)*int(time.Millisecond), time.UTC)
+	return consumeEndObject(er.r)
+}
+
+func consumeEndObject(r io.Reader) error {
+	t, err := readTag(r)
+	if err != nil {
+		return err
+	}
+	if t != tagEndObject {
+		return fmt.Errorf("nettrace: expected EndObject tag, got %d", t)
+	}
+	return nil
+}
+
+// Next returns the next decoded event, or io.EOF once the stream is
+// exhausted.
+func (er *EventReader) Next() (Event, error) {
+	for len(er.pending) == 0 {
+		if er.done {
+			return Event{}, io.EOF
+		}
+		if err := er.readNextBlock(); err != nil {
+			return Event{}, err
+		}
+	}
+	ev := er.pending[0]
+	er.pending = er.pending[1:]
+	return ev, nil
+}
+
+// readNextBlock consumes the next top-level object in the stream. Blocks
+// that don't contribute decodable events (StackBlock, SPBlock) are skipped;
+// EventBlock contents are decoded and queued onto er.pending.
+func (er *EventReader) readNextBlock() error {
+	t, err := readTag(er.r)
+	if err != nil {
+		if err == io.EOF {
+			er.done = true
+			return nil
+		}
+		return err
+	}
+	if t == tagNullReference {
+		er.done = true
+		return nil
+	}
+	if t != tagBeginPrivateObject {
+		return fmt.Errorf("nettrace: unexpected top-level tag %d", t)
+	}
+	name, err := readObjectHeaderBody(er.r)
+	if err != nil {
+		return err
+	}
+	bodyLen, err := readBlockSize(er.r)
+	if err != nil {
+		return err
+	}
+	// Block data is padded to start on a 4-byte boundary relative to the
+	// start of the stream.
+	if pad := -er.r.pos & 3; pad != 0 {
+		if _, err := io.CopyN(io.Discard, er.r, pad); err != nil {
+			return err
+		}
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(er.r, body); err != nil {
+		return err
+	}
+	if err := consumeEndObject(er.r); err != nil {
+		return err
+	}
+
+	br := bytes.NewReader(body)
+	header, err := readBlockHeader(br)
+	if err != nil {
+		return err
+	}
+	rest := body[len(body)-br.Len():]
+
+	switch name {
+	case blockNameMetadataBlock:
+		return decodeMetadataBlock(rest, header, er.metadata)
+	case blockNameEventBlock:
+		return er.decodeEventBlock(rest, header)
+	case blockNameStackBlock, blockNameSPBlock:
+		return nil // not surfaced through Event; stack/sequence-point bookkeeping only.
+	default:
+		return nil
+	}
+}
+
+func (er *EventReader) decodeEventBlock(body []byte, header blockHeader) error {
+	events, err := decodeEventSequence(body, header)
+	if err != nil {
+		return err
+	}
+	for _, de := range events {
+		md := er.metadata[de.metadataID]
+		if md == nil {
+			continue // event for a MetadataId we haven't seen yet; drop rather than fail the stream.
+		}
+		var payload map[string]interface{}
+		if len(md.Fields) > 0 {
+			payload, err = decodePayload(de.payload, md.Fields)
+			if err != nil {
+				return err
+			}
+		}
+		er.pending = append(er.pending, Event{
+			ProviderName: md.ProviderName,
+			ID:           md.EventID,
+			Keywords:     md.Keywords,
+			Level:        md.Level,
+			Timestamp:    er.toWallClock(de.timestamp),
+			ThreadID:     de.threadID,
+			Payload:      payload,
+		})
+	}
+	return nil
+}
+
+func (er *EventReader) toWallClock(qpc int64) time.Time {
+	if er.qpcFreq == 0 {
+		return er.syncTime
+	}
+	delta := time.Duration(qpc-er.syncQPC) * time.Second / time.Duration(er.qpcFreq)
+	return er.syncTime.Add(delta)
+}
+
+// readObjectHeaderBody reads the type descriptor that follows a
+// BeginPrivateObject tag already consumed by the caller, returning the
+// declared type name (EventBlock, MetadataBlock, StackBlock, ...).
+func readObjectHeaderBody(r io.Reader) (string, error) {
+	t, err := readTag(r)
+	if err != nil {
+		return "", err
+	}
+	if t != tagBeginPrivateObject {
+		return "", fmt.Errorf("nettrace: expected type descriptor tag, got %d", t)
+	}
+	// The type descriptor's own type is a NullReference, terminating the
+	// recursion; it has no payload of its own.
+	if t, err = readTag(r); err != nil {
+		return "", err
+	}
+	if t != tagNullReference {
+		return "", fmt.Errorf("nettrace: expected NullReference tag, got %d", t)
+	}
+	var version, minReaderVersion int32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return "", err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &minReaderVersion); err != nil {
+		return "", err
+	}
+	name, err := readLengthPrefixedString(r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := readTag(r); err != nil { // closes the type descriptor object
+		return "", err
+	}
+	return name, nil
+}
+
+func readBlockSize(r io.Reader) (int32, error) {
+	var n int32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("nettrace: negative block size %d", n)
+	}
+	return n, nil
+}
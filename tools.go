@@ -0,0 +1,11 @@
+//go:build tools
+
+// Package dotnetdiag pins the grpc-gateway dependency used by `make proto`
+// (its third_party/googleapis include path is located via `go list -m`) as a
+// real module requirement, so `go mod tidy` doesn't prune it as unused and a
+// fresh checkout's go.sum already covers it.
+package dotnetdiag
+
+import (
+	_ "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package dotnetdiag
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dialTransport connects to the diagnostic IPC Unix domain socket for pid.
+// The runtime creates the socket at
+// $TMPDIR/dotnet-diagnostic-<pid>-<disambiguator>-socket; the disambiguator
+// is the runtime start time and isn't known to us up front, so we glob for
+// it the same way dotnet-trace does.
+func dialTransport(pid int) (io.ReadWriteCloser, error) {
+	path, err := findDiagnosticSocket(pid)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("dial diagnostic socket: %w", err)
+	}
+	return conn, nil
+}
+
+func findDiagnosticSocket(pid int) (string, error) {
+	dir := os.TempDir()
+	pattern := filepath.Join(dir, fmt.Sprintf("dotnet-diagnostic-%d-*-socket", pid))
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", fmt.Errorf("glob diagnostic socket: %w", err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no diagnostic socket found for pid %d in %s", pid, dir)
+	}
+	// Prefer the most recently created socket when multiple runtimes have
+	// raced to create one for the same pid (e.g. after a restart).
+	sort.Slice(matches, func(i, j int) bool {
+		return socketDisambiguator(matches[i]) > socketDisambiguator(matches[j])
+	})
+	return matches[0], nil
+}
+
+func socketDisambiguator(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, "-socket")
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
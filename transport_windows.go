@@ -0,0 +1,28 @@
+//go:build windows
+
+package dotnetdiag
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// dialTransport connects to the diagnostic named pipe for pid. Unlike the
+// Unix domain socket side, there's no disambiguator to enumerate: the .NET
+// runtime always exposes the pipe at the single well-known name
+// \\.\pipe\dotnet-diagnostic-<pid>.
+func dialTransport(pid int) (io.ReadWriteCloser, error) {
+	pipeName := diagnosticPipeName(pid)
+	conn, err := winio.DialPipeContext(context.Background(), pipeName)
+	if err != nil {
+		return nil, fmt.Errorf("dial diagnostic pipe %s: %w", pipeName, err)
+	}
+	return conn, nil
+}
+
+func diagnosticPipeName(pid int) string {
+	return fmt.Sprintf(`\\.\pipe\dotnet-diagnostic-%d`, pid)
+}